@@ -0,0 +1,228 @@
+/*
+ Copyright 2021 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+)
+
+const (
+	defaultGitHubPollInterval = 30 * time.Second
+	defaultSlackPollInterval  = 5 * time.Second
+	slackAPIURL               = "https://slack.com/api/"
+	// cleanupTimeout bounds the deletion of canary comments, which happens
+	// after the probe context is done.
+	cleanupTimeout = 30 * time.Second
+)
+
+// errNoReply is returned when a canary got no reply before the timeout.
+var errNoReply = errors.New("no reply to the canary before the timeout")
+
+// githubCommentProber posts a canary comment on a GitHub issue or pull
+// request and waits for the bot to reply. This goes through the whole
+// delivery path of the bot: GitHub webhook, ingress, EventListener,
+// interceptors and the resulting PipelineRuns. The canary and the reply are
+// deleted once the probe ends, so that they don't pile up on the issue.
+type githubCommentProber struct {
+	client       *github.Client
+	pollInterval time.Duration
+}
+
+func (p *githubCommentProber) probe(ctx context.Context, bot botConfig) probeResult {
+	result := probeResult{Bot: bot.Name, Time: time.Now()}
+	owner, repo := splitOwnerRepo(bot.Repo)
+	canary, _, err := p.client.Issues.CreateComment(ctx, owner, repo, bot.Issue, &github.IssueComment{Body: github.String(bot.Message)})
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to post canary comment: %v", err)
+		return result
+	}
+	result.DeliveryID = strconv.FormatInt(canary.GetID(), 10)
+	since := canary.GetCreatedAt()
+	var reply *github.IssueComment
+	defer func() {
+		p.cleanup(owner, repo, canary, reply)
+	}()
+
+	err = poll(ctx, p.pollInterval, func() (bool, error) {
+		comments, _, err := p.client.Issues.ListComments(ctx, owner, repo, bot.Issue, &github.IssueListCommentsOptions{
+			Since:       &since,
+			ListOptions: github.ListOptions{PerPage: 100},
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, c := range comments {
+			if c.GetID() == canary.GetID() || c.GetCreatedAt().Before(since) {
+				continue
+			}
+			if bot.ReplyUser != "" && c.GetUser().GetLogin() != bot.ReplyUser {
+				continue
+			}
+			if strings.Contains(c.GetBody(), bot.ReplyContains) {
+				reply = c
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	result.Latency = time.Since(result.Time)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// cleanup deletes the canary comment and the bot reply, if any. Failures are
+// only logged, since they don't tell anything about the health of the bot.
+func (p *githubCommentProber) cleanup(owner, repo string, comments ...*github.IssueComment) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	for _, c := range comments {
+		if c == nil {
+			continue
+		}
+		if _, err := p.client.Issues.DeleteComment(ctx, owner, repo, c.GetID()); err != nil {
+			log.Printf("Failed to delete canary comment %s/%s %d: %v", owner, repo, c.GetID(), err)
+		}
+	}
+}
+
+// slackMessageProber posts a canary message on a Slack channel and waits for
+// the bot to reply.
+type slackMessageProber struct {
+	client       *http.Client
+	token        string
+	baseURL      string
+	pollInterval time.Duration
+}
+
+type slackMessage struct {
+	User string `json:"user,omitempty"`
+	Text string `json:"text,omitempty"`
+	TS   string `json:"ts,omitempty"`
+}
+
+type slackResponse struct {
+	OK       bool           `json:"ok"`
+	Error    string         `json:"error,omitempty"`
+	TS       string         `json:"ts,omitempty"`
+	Messages []slackMessage `json:"messages,omitempty"`
+}
+
+func (p *slackMessageProber) probe(ctx context.Context, bot botConfig) probeResult {
+	result := probeResult{Bot: bot.Name, Time: time.Now()}
+	body, err := json.Marshal(map[string]string{"channel": bot.Channel, "text": bot.Message})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	posted, err := p.call(ctx, http.MethodPost, "chat.postMessage", body)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to post canary message: %v", err)
+		return result
+	}
+	result.DeliveryID = posted.TS
+
+	err = poll(ctx, p.pollInterval, func() (bool, error) {
+		q := url.Values{"channel": {bot.Channel}, "oldest": {posted.TS}}
+		history, err := p.call(ctx, http.MethodGet, "conversations.history?"+q.Encode(), nil)
+		if err != nil {
+			return false, err
+		}
+		for _, m := range history.Messages {
+			if m.TS == posted.TS {
+				continue
+			}
+			if bot.ReplyUser != "" && m.User != bot.ReplyUser {
+				continue
+			}
+			if strings.Contains(m.Text, bot.ReplyContains) {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	result.Latency = time.Since(result.Time)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// call invokes a Slack Web API method.
+func (p *slackMessageProber) call(ctx context.Context, method, path string, body []byte) (*slackResponse, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	sr := &slackResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(sr); err != nil {
+		return nil, fmt.Errorf("failed to decode slack response (status %d): %w", resp.StatusCode, err)
+	}
+	if !sr.OK {
+		return nil, fmt.Errorf("slack API error: %s", sr.Error)
+	}
+	return sr, nil
+}
+
+// poll calls check every interval until it returns true or an error, or the
+// context is done.
+func poll(ctx context.Context, interval time.Duration, check func() (bool, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		done, err := check()
+		if err != nil {
+			if ctx.Err() != nil {
+				return errNoReply
+			}
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errNoReply
+		case <-ticker.C:
+		}
+	}
+}
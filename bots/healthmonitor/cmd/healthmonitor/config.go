@@ -0,0 +1,278 @@
+/*
+ Copyright 2021 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInterval         = 5 * time.Minute
+	defaultTimeout          = 30 * time.Second
+	defaultFailureThreshold = 2
+	defaultEvent            = "issue_comment"
+)
+
+// Probe modes, i.e. how a bot is exercised.
+const (
+	// modeWebhook sends a signed synthetic webhook to the bot URL.
+	modeWebhook = "webhook"
+	// modeGitHubComment posts a canary comment on a GitHub issue or pull
+	// request and waits for the bot to reply to it.
+	modeGitHubComment = "github-comment"
+	// modeSlackMessage posts a canary message on a Slack channel and waits
+	// for the bot to reply to it.
+	modeSlackMessage = "slack-message"
+)
+
+// botConfig describes a single bot and how to exercise it.
+type botConfig struct {
+	Name string `json:"name"`
+	// Mode is one of "webhook" (the default), "github-comment" or
+	// "slack-message".
+	Mode string `json:"mode,omitempty"`
+	// Interval overrides the global interval for this bot, e.g. for canaries
+	// which are too expensive to run every few minutes.
+	Interval string `json:"interval,omitempty"`
+	// Timeout overrides the global timeout for this bot.
+	Timeout string `json:"timeout,omitempty"`
+	// Message is the body of the synthetic or canary comment, or of the Slack
+	// message.
+	Message string `json:"message,omitempty"`
+
+	// URL is where the synthetic webhook is sent, in webhook mode.
+	URL string `json:"url,omitempty"`
+	// Event is the X-GitHub-Event sent with the synthetic webhook.
+	Event string `json:"event,omitempty"`
+	// ExpectStatus is the HTTP status code a healthy bot replies with.
+	ExpectStatus int `json:"expectStatus,omitempty"`
+
+	// Repo ("owner/repo") and Issue identify the issue or pull request the
+	// canary comment is posted on. In webhook mode they are only used to
+	// fill in the synthetic event.
+	Repo  string `json:"repo,omitempty"`
+	Issue int    `json:"issue,omitempty"`
+
+	// Channel is the Slack channel the canary message is posted on.
+	Channel string `json:"channel,omitempty"`
+
+	// ReplyContains is a string the bot reply to a canary must contain.
+	ReplyContains string `json:"replyContains,omitempty"`
+	// ReplyUser optionally restricts canary replies to the given GitHub
+	// login or Slack user ID.
+	ReplyUser string `json:"replyUser,omitempty"`
+
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// config is the health monitor configuration, usually mounted from a
+// ConfigMap.
+type config struct {
+	// StatusRepo is the "owner/repo" holding the status issue.
+	StatusRepo string `json:"statusRepo"`
+	// StatusIssue is the number of the issue updated with the bot status.
+	StatusIssue int `json:"statusIssue"`
+	// Interval is how often all bots are probed, e.g. "5m".
+	Interval string `json:"interval,omitempty"`
+	// Timeout bounds each probe, e.g. "30s". In canary modes it is how long
+	// the monitor waits for the reply.
+	Timeout string `json:"timeout,omitempty"`
+	// FailureThreshold is the number of consecutive failed probes before a
+	// bot is reported as down.
+	FailureThreshold int         `json:"failureThreshold,omitempty"`
+	Bots             []botConfig `json:"bots"`
+
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func loadConfig(path string) (*config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	return parseConfig(b)
+}
+
+func parseConfig(b []byte) (*config, error) {
+	c := &config{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := c.setDefaults(); err != nil {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func parseDuration(field, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", field, value, err)
+	}
+	return d, nil
+}
+
+func (c *config) setDefaults() error {
+	var err error
+	if c.interval, err = parseDuration("interval", c.Interval, defaultInterval); err != nil {
+		return err
+	}
+	if c.timeout, err = parseDuration("timeout", c.Timeout, defaultTimeout); err != nil {
+		return err
+	}
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = defaultFailureThreshold
+	}
+	for i := range c.Bots {
+		b := &c.Bots[i]
+		if b.Mode == "" {
+			b.Mode = modeWebhook
+		}
+		if b.interval, err = parseDuration(b.Name+" interval", b.Interval, c.interval); err != nil {
+			return err
+		}
+		if b.timeout, err = parseDuration(b.Name+" timeout", b.Timeout, c.timeout); err != nil {
+			return err
+		}
+		if b.Mode == modeWebhook {
+			if b.Event == "" {
+				b.Event = defaultEvent
+			}
+			if b.ExpectStatus == 0 {
+				b.ExpectStatus = http.StatusOK
+			}
+			if b.Repo == "" {
+				b.Repo = c.StatusRepo
+			}
+			if b.Issue == 0 {
+				b.Issue = c.StatusIssue
+			}
+		}
+	}
+	return nil
+}
+
+func (c *config) validate() error {
+	if len(c.Bots) == 0 {
+		return errors.New("no bots configured")
+	}
+	if c.interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", c.interval)
+	}
+	if c.timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %s", c.timeout)
+	}
+	if c.FailureThreshold < 1 {
+		return fmt.Errorf("failureThreshold must be at least 1, got %d", c.FailureThreshold)
+	}
+	if !isOwnerRepo(c.StatusRepo) {
+		return fmt.Errorf("statusRepo %q is not in the form owner/repo", c.StatusRepo)
+	}
+	if c.StatusIssue < 1 {
+		return errors.New("statusIssue is required")
+	}
+	names := map[string]bool{}
+	for _, b := range c.Bots {
+		if b.Name == "" {
+			return errors.New("every bot needs a name")
+		}
+		if names[b.Name] {
+			return fmt.Errorf("duplicate bot name %q", b.Name)
+		}
+		names[b.Name] = true
+		if err := b.validate(); err != nil {
+			return fmt.Errorf("bot %q: %w", b.Name, err)
+		}
+	}
+	return nil
+}
+
+func (b botConfig) validate() error {
+	if b.interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", b.interval)
+	}
+	// The timeout bounds every probe, so that a hung bot can't block its
+	// probe loop.
+	if b.timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %s", b.timeout)
+	}
+	switch b.Mode {
+	case modeWebhook:
+		if b.URL == "" {
+			return errors.New("url is required in webhook mode")
+		}
+	case modeGitHubComment:
+		if !isOwnerRepo(b.Repo) || b.Issue < 1 {
+			return errors.New("repo (owner/repo) and issue are required in github-comment mode")
+		}
+		if b.Message == "" || b.ReplyContains == "" {
+			return errors.New("message and replyContains are required in github-comment mode")
+		}
+	case modeSlackMessage:
+		if b.Channel == "" {
+			return errors.New("channel is required in slack-message mode")
+		}
+		if b.Message == "" || b.ReplyContains == "" {
+			return errors.New("message and replyContains are required in slack-message mode")
+		}
+	default:
+		return fmt.Errorf("unknown mode %q", b.Mode)
+	}
+	return nil
+}
+
+// usesMode returns true if at least one bot is probed with the given mode.
+func (c *config) usesMode(mode string) bool {
+	for _, b := range c.Bots {
+		if b.Mode == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// statusOwnerRepo splits StatusRepo into its owner and repo parts.
+func (c *config) statusOwnerRepo() (string, string) {
+	return splitOwnerRepo(c.StatusRepo)
+}
+
+func isOwnerRepo(s string) bool {
+	owner, repo := splitOwnerRepo(s)
+	return owner != "" && repo != "" && !strings.Contains(repo, "/")
+}
+
+func splitOwnerRepo(s string) (string, string) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
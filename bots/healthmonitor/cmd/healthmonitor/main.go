@@ -0,0 +1,205 @@
+/*
+ Copyright 2021 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+)
+
+const (
+	// Environment variable containing the path to the configuration file
+	envConfig = "CONFIG_PATH"
+	// Environment variable containing the GitHub webhook secret shared with the bots
+	envSecret = "GITHUB_SECRET_TOKEN"
+	// Environment variable containing the GitHub token used to update the status issue and post canary comments
+	envToken = "GITHUB_TOKEN"
+	// Environment variable containing the Slack token used to post canary messages
+	envSlackToken = "SLACK_TOKEN"
+)
+
+const defaultConfigPath = "/etc/healthmonitor/config.json"
+
+// staleIntervals is the number of intervals after which a bot which wasn't
+// checked makes the monitor unhealthy.
+const staleIntervals = 3
+
+func main() {
+	configPath := os.Getenv(envConfig)
+	if configPath == "" {
+		configPath = defaultConfigPath
+	}
+	conf, err := loadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	token := os.Getenv(envToken)
+	if token == "" {
+		log.Fatalf("No GitHub token given")
+	}
+	ghClient := github.NewClient(&http.Client{Transport: &tokenTransport{token: token}})
+
+	probers := map[string]prober{}
+	if conf.usesMode(modeWebhook) {
+		secret := os.Getenv(envSecret)
+		if secret == "" {
+			log.Fatalf("No secret token given")
+		}
+		probers[modeWebhook] = &webhookProber{client: &http.Client{}, secret: []byte(secret)}
+	}
+	if conf.usesMode(modeGitHubComment) {
+		probers[modeGitHubComment] = &githubCommentProber{client: ghClient, pollInterval: defaultGitHubPollInterval}
+	}
+	if conf.usesMode(modeSlackMessage) {
+		slackToken := os.Getenv(envSlackToken)
+		if slackToken == "" {
+			log.Fatalf("No Slack token given, it is required by slack-message probes")
+		}
+		probers[modeSlackMessage] = &slackMessageProber{client: &http.Client{}, token: slackToken, baseURL: slackAPIURL, pollInterval: defaultSlackPollInterval}
+	}
+
+	owner, repo := conf.statusOwnerRepo()
+	m := newMonitor(conf, probers, &issueReporter{
+		client: ghClient,
+		owner:  owner,
+		repo:   repo,
+		number: conf.StatusIssue,
+	})
+
+	go m.run(context.Background())
+
+	http.Handle("/", m.tracker)
+	http.HandleFunc("/healthz", m.healthz)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", 8080), nil))
+}
+
+// monitor periodically probes the bots and reports their status.
+type monitor struct {
+	conf     *config
+	probers  map[string]prober
+	tracker  *tracker
+	reporter *issueReporter
+
+	mu sync.Mutex
+	// checked is when each bot was last checked, or when the monitor
+	// started.
+	checked map[string]time.Time
+}
+
+func newMonitor(conf *config, probers map[string]prober, reporter *issueReporter) *monitor {
+	m := &monitor{
+		conf:     conf,
+		probers:  probers,
+		tracker:  newTracker(conf.FailureThreshold, conf.Bots),
+		reporter: reporter,
+		checked:  map[string]time.Time{},
+	}
+	now := time.Now()
+	for _, bot := range conf.Bots {
+		m.checked[bot.Name] = now
+	}
+	return m
+}
+
+// run probes each bot on its own interval until the context is done. Bots are
+// probed independently, so that a slow canary doesn't delay the others.
+func (m *monitor) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, bot := range m.conf.Bots {
+		wg.Add(1)
+		go func(bot botConfig) {
+			defer wg.Done()
+			ticker := time.NewTicker(bot.interval)
+			defer ticker.Stop()
+			for {
+				m.check(ctx, bot)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(bot)
+	}
+	wg.Wait()
+}
+
+// check probes a bot, records the result and reports it on the status issue.
+func (m *monitor) check(ctx context.Context, bot botConfig) {
+	probeCtx, cancel := context.WithTimeout(ctx, bot.timeout)
+	defer cancel()
+	r := m.probers[bot.Mode].probe(probeCtx, bot)
+	if r.OK() {
+		log.Printf("Bot %s responded in %s (delivery ID %s)", r.Bot, r.Latency, r.DeliveryID)
+	} else {
+		log.Printf("Bot %s failed health check (delivery ID %s): %s", r.Bot, r.DeliveryID, r.Error)
+	}
+	var changed []string
+	if m.tracker.record(r) {
+		changed = append(changed, r.Bot)
+	}
+	if err := m.reporter.report(ctx, m.tracker.snapshot(), changed); err != nil {
+		log.Printf("Failed to report status: %v", err)
+	}
+	m.mu.Lock()
+	m.checked[bot.Name] = time.Now()
+	m.mu.Unlock()
+}
+
+// stale returns the names of the bots which haven't been checked for a few
+// intervals, which means that their probe loop is stuck.
+func (m *monitor) stale(now time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var stale []string
+	for _, bot := range m.conf.Bots {
+		if now.Sub(m.checked[bot.Name]) > staleIntervals*bot.interval+bot.timeout {
+			stale = append(stale, bot.Name)
+		}
+	}
+	return stale
+}
+
+// healthz fails when the monitor stopped checking bots, so that Kubernetes
+// restarts it.
+func (m *monitor) healthz(w http.ResponseWriter, r *http.Request) {
+	if stale := m.stale(time.Now()); len(stale) > 0 {
+		http.Error(w, fmt.Sprintf("bots not checked for too long: %s", strings.Join(stale, ", ")), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// tokenTransport authenticates GitHub API requests with a token.
+type tokenTransport struct {
+	token string
+}
+
+func (t *tokenTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "token "+t.token)
+	return http.DefaultTransport.RoundTrip(r)
+}
@@ -0,0 +1,524 @@
+/*
+ Copyright 2021 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+)
+
+func TestProbeSignedWebhook(t *testing.T) {
+	var gotComment string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := github.ValidatePayload(r, []byte("secret"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		event, err := github.ParseWebHook(github.WebHookType(r), payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		gotComment = event.(*github.IssueCommentEvent).GetComment().GetBody()
+	}))
+	defer ts.Close()
+
+	conf, err := parseConfig([]byte(`{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "bots": [{"name": "mario", "url": "` + ts.URL + `", "message": "/mario build a b"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &webhookProber{client: ts.Client(), secret: []byte("secret")}
+
+	r := p.probe(context.Background(), conf.Bots[0])
+
+	if !r.OK() {
+		t.Fatalf("probe failed: %s", r.Error)
+	}
+	if gotComment != "/mario build a b" {
+		t.Errorf("got comment %q, want %q", gotComment, "/mario build a b")
+	}
+}
+
+func TestProbeUnexpectedStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not a Mario command", http.StatusBadRequest)
+	}))
+	defer ts.Close()
+	p := &webhookProber{client: ts.Client(), secret: []byte("secret")}
+
+	r := p.probe(context.Background(), botConfig{Name: "mario", URL: ts.URL, Event: "issue_comment", ExpectStatus: http.StatusOK})
+
+	if r.OK() {
+		t.Fatal("expected probe to fail")
+	}
+	if r.StatusCode != http.StatusBadRequest || !strings.Contains(r.Error, "not a Mario command") {
+		t.Errorf("unexpected result: %+v", r)
+	}
+}
+
+func TestTrackerFailureThreshold(t *testing.T) {
+	tr := newTracker(2, []botConfig{{Name: "mario"}})
+	failed := probeResult{Bot: "mario", Error: "boom"}
+
+	if tr.record(failed) {
+		t.Error("bot should not go down before reaching the failure threshold")
+	}
+	if !tr.record(failed) {
+		t.Error("bot should go down when reaching the failure threshold")
+	}
+	if tr.record(failed) {
+		t.Error("bot already down should not change state")
+	}
+	if !tr.record(probeResult{Bot: "mario", Time: time.Now()}) {
+		t.Error("bot should recover after a successful probe")
+	}
+	if s := tr.snapshot()[0]; !s.Up || s.ConsecutiveFailures != 0 {
+		t.Errorf("unexpected status after recovery: %+v", s)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	conf, err := parseConfig([]byte(`{
+		"statusRepo": "tektoncd/plumbing",
+		"statusIssue": 1,
+		"bots": [
+			{"name": "hook", "url": "http://hook"},
+			{"name": "mario", "mode": "github-comment", "interval": "1h", "timeout": "20m", "repo": "tektoncd/sandbox", "issue": 2, "message": "/mario build a b", "replyContains": "at your service"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hook, mario := conf.Bots[0], conf.Bots[1]
+	if hook.Mode != modeWebhook || hook.Event != defaultEvent || hook.ExpectStatus != http.StatusOK || hook.interval != defaultInterval || hook.timeout != defaultTimeout {
+		t.Errorf("unexpected defaults for webhook bot: %+v", hook)
+	}
+	if hook.Repo != "tektoncd/plumbing" || hook.Issue != 1 {
+		t.Errorf("webhook bot should default to the status issue, got %s#%d", hook.Repo, hook.Issue)
+	}
+	if mario.interval != time.Hour || mario.timeout != 20*time.Minute || mario.Repo != "tektoncd/sandbox" || mario.Issue != 2 {
+		t.Errorf("per-bot settings not honoured: %+v", mario)
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		config string
+	}{{
+		name:   "no bots",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1}`,
+	}, {
+		name:   "missing url",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "bots": [{"name": "mario"}]}`,
+	}, {
+		name:   "duplicate name",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "bots": [{"name": "mario", "url": "a"}, {"name": "mario", "url": "b"}]}`,
+	}, {
+		name:   "bad interval",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "interval": "often", "bots": [{"name": "mario", "url": "a"}]}`,
+	}, {
+		name:   "zero interval",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "interval": "0s", "bots": [{"name": "mario", "url": "a"}]}`,
+	}, {
+		name:   "negative interval",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "interval": "-5m", "bots": [{"name": "mario", "url": "a"}]}`,
+	}, {
+		name:   "negative timeout",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "timeout": "-1s", "bots": [{"name": "mario", "url": "a"}]}`,
+	}, {
+		name:   "zero bot timeout in webhook mode",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "bots": [{"name": "mario", "url": "a", "timeout": "0s"}]}`,
+	}, {
+		name:   "negative failure threshold",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "failureThreshold": -1, "bots": [{"name": "mario", "url": "a"}]}`,
+	}, {
+		name:   "unknown mode",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "bots": [{"name": "mario", "mode": "carrier-pigeon"}]}`,
+	}, {
+		name:   "github-comment without repo",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "bots": [{"name": "mario", "mode": "github-comment", "issue": 1, "message": "/mario build a b", "replyContains": "at your service"}]}`,
+	}, {
+		name:   "github-comment without issue",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "bots": [{"name": "mario", "mode": "github-comment", "repo": "tektoncd/sandbox", "message": "/mario build a b", "replyContains": "at your service"}]}`,
+	}, {
+		name:   "github-comment without timeout",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "bots": [{"name": "mario", "mode": "github-comment", "timeout": "0s", "repo": "tektoncd/sandbox", "issue": 1, "message": "/mario build a b", "replyContains": "at your service"}]}`,
+	}, {
+		name:   "slack-message without channel",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "bots": [{"name": "buildcaptain", "mode": "slack-message", "message": "status", "replyContains": "Build Captain"}]}`,
+	}, {
+		name:   "slack-message without reply",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "bots": [{"name": "buildcaptain", "mode": "slack-message", "channel": "D123", "message": "status"}]}`,
+	}, {
+		name:   "negative bot interval",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 1, "bots": [{"name": "mario", "url": "a", "interval": "-1h"}]}`,
+	}, {
+		name:   "missing status issue",
+		config: `{"statusRepo": "tektoncd/plumbing", "bots": [{"name": "mario", "url": "a"}]}`,
+	}, {
+		name:   "placeholder status issue",
+		config: `{"statusRepo": "tektoncd/plumbing", "statusIssue": 0, "bots": [{"name": "mario", "url": "a"}]}`,
+	}, {
+		name:   "missing status repo",
+		config: `{"statusIssue": 1, "bots": [{"name": "mario", "url": "a"}]}`,
+	}, {
+		name:   "bad status repo",
+		config: `{"statusIssue": 1, "statusRepo": "plumbing", "bots": [{"name": "mario", "url": "a"}]}`,
+	}} {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := parseConfig([]byte(c.config)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestRenderStatus(t *testing.T) {
+	since := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	body := renderStatus([]botStatus{
+		{Name: "buildcaptain", Up: true, Since: since, LastResult: probeResult{Latency: 120 * time.Millisecond}},
+		{Name: "mario", Up: false, Since: since, LastResult: probeResult{Error: "a | b"}},
+	})
+
+	for _, want := range []string{
+		"| buildcaptain | :white_check_mark: up | 2021-06-01T12:00:00Z |  |",
+		"| mario | :x: down | 2021-06-01T12:00:00Z | a \\| b |",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("status body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+// fakeProber returns the queued results in order.
+type fakeProber struct {
+	results []probeResult
+}
+
+func (p *fakeProber) probe(ctx context.Context, bot botConfig) probeResult {
+	r := p.results[0]
+	p.results = p.results[1:]
+	r.Bot = bot.Name
+	r.Time = time.Now()
+	return r
+}
+
+// fakeStatusIssue is a fake GitHub API serving the status issue, which fails
+// the given number of edits and comments before accepting them.
+type fakeStatusIssue struct {
+	edits, comments         []string
+	failEdits, failComments int
+}
+
+func (f *fakeStatusIssue) start(t *testing.T) *issueReporter {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/tektoncd/plumbing/issues/1", func(w http.ResponseWriter, r *http.Request) {
+		if f.failEdits > 0 {
+			f.failEdits--
+			http.Error(w, "boom", http.StatusBadGateway)
+			return
+		}
+		req := &github.IssueRequest{}
+		json.NewDecoder(r.Body).Decode(req)
+		f.edits = append(f.edits, req.GetBody())
+		w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("/repos/tektoncd/plumbing/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		if f.failComments > 0 {
+			f.failComments--
+			http.Error(w, "boom", http.StatusBadGateway)
+			return
+		}
+		c := &github.IssueComment{}
+		json.NewDecoder(r.Body).Decode(c)
+		f.comments = append(f.comments, c.GetBody())
+		w.Write([]byte("{}"))
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	client := github.NewClient(ts.Client())
+	client.BaseURL, _ = url.Parse(ts.URL + "/")
+	return &issueReporter{client: client, owner: "tektoncd", repo: "plumbing", number: 1}
+}
+
+func TestMonitorReportsChanges(t *testing.T) {
+	gh := &fakeStatusIssue{}
+	bot := botConfig{Name: "mario", Mode: modeWebhook, timeout: time.Second}
+	conf := &config{FailureThreshold: 2, Bots: []botConfig{bot}}
+	p := &fakeProber{results: []probeResult{{}, {}, {Error: "boom"}, {Error: "boom"}, {Error: "boom"}, {}}}
+	m := newMonitor(conf, map[string]prober{modeWebhook: p}, gh.start(t))
+
+	for _, step := range []struct {
+		name     string
+		edits    int
+		comments int
+	}{
+		{name: "first report", edits: 1, comments: 0},
+		{name: "still up", edits: 1, comments: 0},
+		{name: "first failure, below threshold", edits: 2, comments: 0},
+		{name: "down", edits: 3, comments: 1},
+		{name: "still down with the same error", edits: 3, comments: 1},
+		{name: "recovered", edits: 4, comments: 2},
+	} {
+		m.check(context.Background(), bot)
+		if len(gh.edits) != step.edits || len(gh.comments) != step.comments {
+			t.Fatalf("%s: got %d edits and %d comments, want %d and %d", step.name, len(gh.edits), len(gh.comments), step.edits, step.comments)
+		}
+	}
+	if !strings.Contains(gh.comments[0], "`mario` is not responding: boom") {
+		t.Errorf("unexpected down comment: %q", gh.comments[0])
+	}
+	if !strings.Contains(gh.comments[1], "`mario` recovered") {
+		t.Errorf("unexpected recovery comment: %q", gh.comments[1])
+	}
+	if !strings.Contains(gh.edits[1], "| mario | :white_check_mark: up |") || !strings.Contains(gh.edits[1], "| boom |") {
+		t.Errorf("status issue should show the error before the bot is down:\n%s", gh.edits[1])
+	}
+}
+
+func TestMonitorRetriesFailedReports(t *testing.T) {
+	gh := &fakeStatusIssue{failEdits: 1, failComments: 1}
+	bot := botConfig{Name: "mario", Mode: modeWebhook, timeout: time.Second}
+	conf := &config{FailureThreshold: 1, Bots: []botConfig{bot}}
+	p := &fakeProber{results: []probeResult{{Error: "boom"}, {Error: "boom"}}}
+	m := newMonitor(conf, map[string]prober{modeWebhook: p}, gh.start(t))
+
+	// The bot goes down while GitHub fails both the edit and the comment.
+	m.check(context.Background(), bot)
+	if len(gh.edits) != 0 || len(gh.comments) != 0 {
+		t.Fatalf("got %d edits and %d comments while GitHub fails, want none", len(gh.edits), len(gh.comments))
+	}
+
+	// The bot is still down, the status change must not be lost.
+	m.check(context.Background(), bot)
+	if len(gh.edits) != 1 || len(gh.comments) != 1 {
+		t.Fatalf("got %d edits and %d comments after GitHub recovered, want 1 and 1", len(gh.edits), len(gh.comments))
+	}
+	if !strings.Contains(gh.comments[0], "`mario` is not responding: boom") {
+		t.Errorf("unexpected down comment: %q", gh.comments[0])
+	}
+}
+
+func TestReportCommentsWhenEditFails(t *testing.T) {
+	gh := &fakeStatusIssue{failEdits: 1}
+	r := gh.start(t)
+	statuses := []botStatus{{Name: "mario", Up: false, LastResult: probeResult{Error: "boom"}}}
+
+	if err := r.report(context.Background(), statuses, []string{"mario"}); err == nil {
+		t.Error("expected the failed edit to be reported")
+	}
+	if len(gh.comments) != 1 {
+		t.Errorf("got %d comments, want the status change to be posted even if the edit failed", len(gh.comments))
+	}
+	if err := r.report(context.Background(), statuses, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(gh.edits) != 1 || len(gh.comments) != 1 {
+		t.Errorf("got %d edits and %d comments, want the edit retried and no new comment", len(gh.edits), len(gh.comments))
+	}
+}
+
+func TestGitHubCommentCanary(t *testing.T) {
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	listed := 0
+	var deleted []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/tektoncd/sandbox/issues/comments/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/repos/tektoncd/sandbox/issues/comments/"))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/repos/tektoncd/sandbox/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&github.IssueComment{ID: github.Int64(10), Body: github.String("/mario build a b"), CreatedAt: &created})
+			return
+		}
+		listed++
+		comments := []*github.IssueComment{{ID: github.Int64(10), Body: github.String("/mario build a b"), CreatedAt: &created}}
+		if listed > 1 {
+			// Only reply on the second poll, to exercise polling.
+			reply := created.Add(time.Minute)
+			comments = append(comments,
+				&github.IssueComment{ID: github.Int64(11), Body: github.String("mario at your service!"), CreatedAt: &reply, User: &github.User{Login: github.String("someone-else")}},
+				&github.IssueComment{ID: github.Int64(12), Body: github.String("mario at your service!"), CreatedAt: &reply, User: &github.User{Login: github.String("tekton-robot")}},
+			)
+		}
+		json.NewEncoder(w).Encode(comments)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := github.NewClient(ts.Client())
+	client.BaseURL, _ = url.Parse(ts.URL + "/")
+	p := &githubCommentProber{client: client, pollInterval: time.Millisecond}
+	bot := botConfig{Name: "mario", Mode: modeGitHubComment, Repo: "tektoncd/sandbox", Issue: 1, Message: "/mario build a b", ReplyContains: "at your service", ReplyUser: "tekton-robot"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r := p.probe(ctx, bot)
+
+	if !r.OK() {
+		t.Fatalf("probe failed: %s", r.Error)
+	}
+	if r.DeliveryID != "10" || listed != 2 {
+		t.Errorf("unexpected result %+v after %d polls", r, listed)
+	}
+	if strings.Join(deleted, ",") != "10,12" {
+		t.Errorf("got deleted comments %v, want the canary and the reply [10 12]", deleted)
+	}
+}
+
+func TestGitHubCommentCanaryNoReply(t *testing.T) {
+	created := time.Now()
+	var deleted []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&github.IssueComment{ID: github.Int64(10), CreatedAt: &created})
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+	client := github.NewClient(ts.Client())
+	client.BaseURL, _ = url.Parse(ts.URL + "/")
+	p := &githubCommentProber{client: client, pollInterval: time.Millisecond}
+	bot := botConfig{Name: "mario", Mode: modeGitHubComment, Repo: "tektoncd/sandbox", Issue: 1, Message: "/mario build a b", ReplyContains: "at your service"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r := p.probe(ctx, bot)
+
+	if r.Error != errNoReply.Error() {
+		t.Errorf("got error %q, want %q", r.Error, errNoReply)
+	}
+	if len(deleted) != 1 || deleted[0] != "/repos/tektoncd/sandbox/issues/comments/10" {
+		t.Errorf("got deleted comments %v, want the canary deleted after the timeout", deleted)
+	}
+}
+
+func TestSlackMessageCanary(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.Write([]byte(`{"ok": false, "error": "invalid_auth"}`))
+			return
+		}
+		w.Write([]byte(`{"ok": true, "ts": "100.1"}`))
+	})
+	mux.HandleFunc("/conversations.history", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("channel") != "D123" || r.URL.Query().Get("oldest") != "100.1" {
+			w.Write([]byte(`{"ok": false, "error": "bad_query"}`))
+			return
+		}
+		w.Write([]byte(`{"ok": true, "messages": [{"user": "U1", "text": "status", "ts": "100.1"}, {"user": "URCPZNB37", "text": "<@U2> is the Build Captain", "ts": "100.2"}]}`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	p := &slackMessageProber{client: ts.Client(), token: "token", baseURL: ts.URL + "/", pollInterval: time.Millisecond}
+	bot := botConfig{Name: "buildcaptain", Mode: modeSlackMessage, Channel: "D123", Message: "status", ReplyContains: "is the Build Captain", ReplyUser: "URCPZNB37"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r := p.probe(ctx, bot)
+
+	if !r.OK() {
+		t.Fatalf("probe failed: %s", r.Error)
+	}
+	if r.DeliveryID != "100.1" {
+		t.Errorf("got delivery ID %q, want %q", r.DeliveryID, "100.1")
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	bot := botConfig{Name: "mario", Mode: modeWebhook, interval: time.Minute, timeout: 30 * time.Second}
+	conf := &config{FailureThreshold: 1, Bots: []botConfig{bot}}
+	m := newMonitor(conf, map[string]prober{modeWebhook: &fakeProber{results: []probeResult{{}}}}, (&fakeStatusIssue{}).start(t))
+
+	w := httptest.NewRecorder()
+	m.healthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d for a fresh monitor, want %d", w.Code, http.StatusOK)
+	}
+
+	if stale := m.stale(time.Now().Add(4 * time.Minute)); len(stale) != 1 || stale[0] != "mario" {
+		t.Errorf("got stale bots %v, want [mario]", stale)
+	}
+
+	m.mu.Lock()
+	m.checked["mario"] = time.Now().Add(-time.Hour)
+	m.mu.Unlock()
+	w = httptest.NewRecorder()
+	m.healthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d for a stuck monitor, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	m.check(context.Background(), bot)
+	w = httptest.NewRecorder()
+	m.healthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d after a check, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestServeStatus(t *testing.T) {
+	tr := newTracker(1, []botConfig{{Name: "buildcaptain"}, {Name: "mario"}})
+	tr.record(probeResult{Bot: "mario", Time: time.Now(), Latency: 1500 * time.Millisecond})
+	tr.record(probeResult{Bot: "buildcaptain", Time: time.Now(), Latency: time.Second, Error: "boom"})
+
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var got []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d statuses, want 2", len(got))
+	}
+	if _, ok := got[0]["lastSuccess"]; ok {
+		t.Errorf("lastSuccess should be omitted for a bot which never replied: %v", got[0])
+	}
+	if _, ok := got[1]["lastSuccess"]; !ok {
+		t.Errorf("lastSuccess missing: %v", got[1])
+	}
+	lastResult := got[1]["lastResult"].(map[string]interface{})
+	if lastResult["latencyMs"] != float64(1500) {
+		t.Errorf("got latencyMs %v, want 1500", lastResult["latencyMs"])
+	}
+	if _, ok := lastResult["Latency"]; ok {
+		t.Errorf("latency should only be serialized in milliseconds: %v", lastResult)
+	}
+}
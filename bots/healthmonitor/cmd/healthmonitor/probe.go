@@ -0,0 +1,150 @@
+/*
+ Copyright 2021 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// probeResult records the outcome of a single probe.
+type probeResult struct {
+	Bot string `json:"bot"`
+	// DeliveryID identifies the synthetic webhook delivery, canary comment or
+	// canary message.
+	DeliveryID string    `json:"deliveryID"`
+	Time       time.Time `json:"time"`
+	// Latency is serialized in milliseconds, as latencyMs.
+	Latency    time.Duration `json:"-"`
+	StatusCode int           `json:"statusCode,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// MarshalJSON serializes the latency in milliseconds, which is easier to
+// consume for dashboards than nanoseconds.
+func (r probeResult) MarshalJSON() ([]byte, error) {
+	type result probeResult
+	return json.Marshal(struct {
+		result
+		LatencyMs int64 `json:"latencyMs"`
+	}{
+		result:    result(r),
+		LatencyMs: r.Latency.Milliseconds(),
+	})
+}
+
+// OK returns true if the bot replied as expected.
+func (r probeResult) OK() bool {
+	return r.Error == ""
+}
+
+// prober exercises a bot and reports whether it responded.
+type prober interface {
+	probe(ctx context.Context, bot botConfig) probeResult
+}
+
+// webhookProber delivers signed synthetic webhooks to bots.
+type webhookProber struct {
+	client *http.Client
+	secret []byte
+}
+
+// probe sends a synthetic webhook to the bot and checks its response.
+func (p *webhookProber) probe(ctx context.Context, bot botConfig) probeResult {
+	result := probeResult{
+		Bot:        bot.Name,
+		DeliveryID: newDeliveryID(),
+		Time:       time.Now(),
+	}
+	payload, err := p.payload(bot)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, bot.URL, bytes.NewReader(payload))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", bot.Event)
+	req.Header.Set("X-GitHub-Delivery", result.DeliveryID)
+	if len(p.secret) > 0 {
+		req.Header.Set("X-Hub-Signature", "sha1="+sign(payload, p.secret, sha1.New))
+		req.Header.Set("X-Hub-Signature-256", "sha256="+sign(payload, p.secret, sha256.New))
+	}
+
+	resp, err := p.client.Do(req)
+	result.Latency = time.Since(result.Time)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode != bot.ExpectStatus {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		result.Error = fmt.Sprintf("expected status %d, got %d: %s", bot.ExpectStatus, resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return result
+}
+
+// payload builds the synthetic event body for the bot.
+func (p *webhookProber) payload(bot botConfig) ([]byte, error) {
+	repo := &github.Repository{FullName: github.String(bot.Repo)}
+	switch bot.Event {
+	case "issue_comment":
+		return json.Marshal(&github.IssueCommentEvent{
+			Action:  github.String("created"),
+			Issue:   &github.Issue{Number: github.Int(bot.Issue)},
+			Comment: &github.IssueComment{Body: github.String(bot.Message)},
+			Repo:    repo,
+		})
+	case "ping":
+		return json.Marshal(&github.PingEvent{Zen: github.String("health check")})
+	default:
+		return nil, fmt.Errorf("unsupported probe event %q", bot.Event)
+	}
+}
+
+func sign(payload, secret []byte, hashFunc func() hash.Hash) string {
+	mac := hmac.New(hashFunc, secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("healthmonitor-%d", time.Now().UnixNano())
+	}
+	return "healthmonitor-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,207 @@
+/*
+ Copyright 2021 The Tekton Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// botStatus is the health of a single bot, derived from its recent probes.
+type botStatus struct {
+	Name                string `json:"name"`
+	Up                  bool   `json:"up"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	// Since is when the bot last went up or down, or when the monitor
+	// started.
+	Since time.Time `json:"since"`
+	// LastSuccess is nil if the bot didn't reply since the monitor started.
+	LastSuccess *time.Time  `json:"lastSuccess,omitempty"`
+	LastResult  probeResult `json:"lastResult"`
+}
+
+// tracker keeps the status of every bot across probe rounds.
+type tracker struct {
+	mu               sync.Mutex
+	failureThreshold int
+	bots             map[string]*botStatus
+}
+
+func newTracker(failureThreshold int, bots []botConfig) *tracker {
+	now := time.Now()
+	t := &tracker{
+		failureThreshold: failureThreshold,
+		bots:             map[string]*botStatus{},
+	}
+	for _, b := range bots {
+		// Bots are assumed healthy until proven otherwise, so that a
+		// restart of the monitor doesn't report an outage.
+		t.bots[b.Name] = &botStatus{Name: b.Name, Up: true, Since: now}
+	}
+	return t
+}
+
+// record stores a probe result and returns true if the bot went up or down
+// as a consequence.
+func (t *tracker) record(r probeResult) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.bots[r.Bot]
+	if !ok {
+		s = &botStatus{Name: r.Bot, Up: true, Since: r.Time}
+		t.bots[r.Bot] = s
+	}
+	s.LastResult = r
+	wasUp := s.Up
+	if r.OK() {
+		s.ConsecutiveFailures = 0
+		last := r.Time
+		s.LastSuccess = &last
+		s.Up = true
+	} else {
+		s.ConsecutiveFailures++
+		if s.ConsecutiveFailures >= t.failureThreshold {
+			s.Up = false
+		}
+	}
+	if wasUp == s.Up {
+		return false
+	}
+	s.Since = r.Time
+	return true
+}
+
+// snapshot returns a copy of the status of all bots, sorted by name.
+func (t *tracker) snapshot() []botStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	statuses := make([]botStatus, 0, len(t.bots))
+	for _, s := range t.bots {
+		statuses = append(statuses, *s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// ServeHTTP exposes the current status as JSON, so that it can be scraped by
+// a dashboard.
+func (t *tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(t.snapshot()); err != nil {
+		log.Printf("Failed to write status: %q", err)
+	}
+}
+
+// renderStatus formats the bot statuses as the markdown body of the status
+// issue. It only changes when a bot goes up or down or its error changes, so
+// that the issue isn't edited on every probe.
+func renderStatus(statuses []botStatus) string {
+	var sb strings.Builder
+	sb.WriteString("# Plumbing bots status\n\n")
+	sb.WriteString("This issue is updated automatically by the health monitor, do not edit it by hand.\n\n")
+	sb.WriteString("| Bot | Status | Since | Last error |\n")
+	sb.WriteString("| --- | ------ | ----- | ---------- |\n")
+	for _, s := range statuses {
+		status := ":white_check_mark: up"
+		if !s.Up {
+			status = ":x: down"
+		}
+		lastError := strings.ReplaceAll(s.LastResult.Error, "|", "\\|")
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s |\n", s.Name, status, s.Since.UTC().Format(time.RFC3339), lastError)
+	}
+	return sb.String()
+}
+
+// issueReporter updates the status issue on GitHub.
+type issueReporter struct {
+	// mu serializes reports, which come from the probe of each bot.
+	mu     sync.Mutex
+	client *github.Client
+	owner  string
+	repo   string
+	number int
+
+	// lastBody is the body the status issue was last updated with.
+	lastBody string
+	// pending holds the bots which went up or down since the last comment
+	// successfully posted on the status issue, so that a GitHub error
+	// doesn't lose the notification.
+	pending []string
+}
+
+// report overwrites the status issue body when it changed, and comments on it
+// when bots went down or recovered so that watchers of the issue get notified.
+// Status changes are kept until the comment is posted, so that they are
+// retried on the next report when GitHub fails.
+func (r *issueReporter) report(ctx context.Context, statuses []botStatus, changed []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range changed {
+		if !contains(r.pending, name) {
+			r.pending = append(r.pending, name)
+		}
+	}
+	var errs []string
+	if body := renderStatus(statuses); body != r.lastBody {
+		if _, _, err := r.client.Issues.Edit(ctx, r.owner, r.repo, r.number, &github.IssueRequest{Body: github.String(body)}); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to update status issue %s/%s#%d: %v", r.owner, r.repo, r.number, err))
+		} else {
+			r.lastBody = body
+		}
+	}
+	if len(r.pending) > 0 {
+		comment := "Bot status changed:\n\n"
+		for _, s := range statuses {
+			if !contains(r.pending, s.Name) {
+				continue
+			}
+			if s.Up {
+				comment += fmt.Sprintf("- :white_check_mark: `%s` recovered\n", s.Name)
+			} else {
+				comment += fmt.Sprintf("- :x: `%s` is not responding: %s\n", s.Name, s.LastResult.Error)
+			}
+		}
+		if _, _, err := r.client.Issues.CreateComment(ctx, r.owner, r.repo, r.number, &github.IssueComment{Body: github.String(comment)}); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to comment on status issue %s/%s#%d: %v", r.owner, r.repo, r.number, err))
+		} else {
+			r.pending = nil
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,5 @@
+module github.com/tektoncd/plumbing/bots/healthmonitor
+
+go 1.14
+
+require github.com/google/go-github/v29 v29.0.3